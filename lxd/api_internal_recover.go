@@ -3,15 +3,24 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
@@ -38,9 +47,15 @@ var internalRecoverImportCmd = APIEndpoint{
 	Post: APIEndpointAction{Handler: internalRecoverImport},
 }
 
+var internalRecoverImportBackupCmd = APIEndpoint{
+	Path: "recover/backup",
+
+	Post: APIEndpointAction{Handler: internalRecoverImportBackup},
+}
+
 // init recover adds API endpoints to handler slice.
 func init() {
-	apiInternal = append(apiInternal, internalRecoverValidateCmd, internalRecoverImportCmd)
+	apiInternal = append(apiInternal, internalRecoverValidateCmd, internalRecoverImportCmd, internalRecoverImportBackupCmd)
 }
 
 // internalRecoverValidatePost is used to initiate a recovery validation scan.
@@ -50,26 +65,354 @@ type internalRecoverValidatePost struct {
 
 // internalRecoverValidateVolume provides info about a missing volume that the recovery validation scan found.
 type internalRecoverValidateVolume struct {
-	Name          string `json:"name" yaml:"name"`                   // Name of volume.
-	Type          string `json:"type" yaml:"type"`                   // Same as Type from StorageVolumesPost (container, custom or virtual-machine).
-	SnapshotCount int    `json:"snapshotCount" yaml:"snapshotCount"` // Count of snapshots found for volume.
-	Project       string `json:"project" yaml:"project"`             // Project the volume belongs to.
-	Pool          string `json:"pool" yaml:"pool"`                   // Pool the volume belongs to.
+	Name              string   `json:"name" yaml:"name"`                           // Name of volume.
+	Type              string   `json:"type" yaml:"type"`                           // Same as Type from StorageVolumesPost (container, custom or virtual-machine).
+	SnapshotCount     int      `json:"snapshotCount" yaml:"snapshotCount"`         // Count of snapshots found for volume.
+	Project           string   `json:"project" yaml:"project"`                     // Project the volume belongs to.
+	Pool              string   `json:"pool" yaml:"pool"`                           // Pool the volume belongs to.
+	MissingProfiles   []string `json:"missingProfiles" yaml:"missingProfiles"`     // Profiles used by volume that don't exist.
+	MissingNetworks   []string `json:"missingNetworks" yaml:"missingNetworks"`     // Networks used by volume that don't exist.
+	MissingProject    bool     `json:"missingProject" yaml:"missingProject"`       // Project the volume belongs to doesn't exist.
+	InstanceConflicts []string `json:"instanceConflicts" yaml:"instanceConflicts"` // Names that already exist in the DB.
+	PoolConflicts     []string `json:"poolConflicts" yaml:"poolConflicts"`         // Pool config mismatches preventing import.
+	Importable        bool     `json:"importable" yaml:"importable"`               // Whether this volume can be imported as-is.
+}
+
+// internalRecoverValidateResultSummary provides aggregate counts for a validation scan.
+type internalRecoverValidateResultSummary struct {
+	Instances     int              `json:"instances" yaml:"instances"`         // Count of instances found.
+	Snapshots     int              `json:"snapshots" yaml:"snapshots"`         // Count of snapshots found.
+	CustomVolumes int              `json:"customVolumes" yaml:"customVolumes"` // Count of custom volumes found.
+	BytesByPool   map[string]int64 `json:"bytesByPool" yaml:"bytesByPool"`     // Sum of the volumes' configured "size", keyed by pool name (volumes without a size add 0).
 }
 
 // internalRecoverValidateResult returns the result of the validation scan.
 type internalRecoverValidateResult struct {
-	UnknownVolumes   []internalRecoverValidateVolume // Volumes that could be imported.
-	DependencyErrors []string                        // Errors that are preventing import from proceeding.
+	UnknownVolumes   []internalRecoverValidateVolume      // Volumes that could be imported, with per-volume diagnostics.
+	Summary          internalRecoverValidateResultSummary // Aggregate counts across all discovered volumes.
+	DependencyErrors []string                             // Deprecated: use the per-volume diagnostics in UnknownVolumes instead.
+}
+
+// Conflict resolution policies for internalRecoverImportPost.ConflictPolicy.
+const (
+	internalRecoverConflictPolicySkip   = "skip"
+	internalRecoverConflictPolicyRename = "rename"
+	internalRecoverConflictPolicyFail   = "fail"
+)
+
+// internalRecoverVolumeSelection identifies a single discovered volume to import.
+type internalRecoverVolumeSelection struct {
+	Pool    string `json:"pool" yaml:"pool"`
+	Project string `json:"project" yaml:"project"`
+	Volume  string `json:"volume" yaml:"volume"`
+	Type    string `json:"type" yaml:"type"`
 }
 
 // internalRecoverImportPost is used to initiate a recovert import.
 type internalRecoverImportPost struct {
 	Pools []api.StoragePoolsPost `json:"pools" yaml:"pools"`
+
+	// Selection restricts the import to the listed volumes. An empty (or omitted) Selection imports
+	// every volume discovered on the supplied pools, preserving the previous all-or-nothing behaviour.
+	Selection []internalRecoverVolumeSelection `json:"selection" yaml:"selection"`
+
+	// ConflictPolicy controls what happens when a recovered volume's name already exists in the target
+	// project: "skip" (default) leaves the existing record alone and skips the import of that volume,
+	// "rename" imports it under the name found in RenameMap, and "fail" aborts the whole import.
+	ConflictPolicy string `json:"conflictPolicy" yaml:"conflictPolicy"`
+
+	// RenameMap maps a conflicting volume name to the name it should be imported as when ConflictPolicy
+	// is "rename".
+	RenameMap map[string]string `json:"renameMap" yaml:"renameMap"`
+}
+
+// internalRecoverIsSelected returns whether a discovered volume should be imported, based on the supplied
+// selection list. An empty selection means import everything.
+func internalRecoverIsSelected(selection []internalRecoverVolumeSelection, pool string, projectName string, volName string, volType string) bool {
+	if len(selection) == 0 {
+		return true
+	}
+
+	for _, sel := range selection {
+		if sel.Pool == pool && sel.Project == projectName && sel.Volume == volName && sel.Type == volType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// internalRecoverResolveConflict applies the conflict resolution policy when a recovered entity's name
+// already exists in the target project. It returns the name to import under and whether to skip it.
+func internalRecoverResolveConflict(conflictPolicy string, renameMap map[string]string, name string, exists bool) (string, bool, error) {
+	if !exists {
+		return name, false, nil
+	}
+
+	switch conflictPolicy {
+	case internalRecoverConflictPolicySkip, "":
+		return name, true, nil
+	case internalRecoverConflictPolicyRename:
+		newName := renameMap[name]
+		if newName == "" {
+			return "", false, fmt.Errorf("No rename specified in renameMap for conflicting name %q", name)
+		}
+
+		return newName, false, nil
+	case internalRecoverConflictPolicyFail:
+		return "", false, fmt.Errorf("Name %q already exists", name)
+	default:
+		return "", false, fmt.Errorf("Invalid conflict policy %q", conflictPolicy)
+	}
+}
+
+// internalRecoverProgress is the operation metadata updated as a recovery scan or import progresses, so that
+// lxc monitor and lxc operation show can report meaningful feedback for long running recoveries.
+type internalRecoverProgress struct {
+	Stage         string `json:"stage" yaml:"stage"`                 // "scan" or "import".
+	Current       int    `json:"current" yaml:"current"`             // Number of pools (scan) or volumes (import) processed so far.
+	Total         int    `json:"total" yaml:"total"`                 // Total number of pools (scan) or volumes (import) to process.
+	CurrentVolume string `json:"currentVolume" yaml:"currentVolume"` // Name of the pool (scan) or volume (import) currently being processed.
+}
+
+// internalRecoverUpdateProgress pushes progress metadata to the operation, if one is associated with this scan.
+func internalRecoverUpdateProgress(op *operations.Operation, stage string, current int, total int, currentVolume string) {
+	if op == nil {
+		return
+	}
+
+	err := op.UpdateMetadata(internalRecoverProgress{
+		Stage:         stage,
+		Current:       current,
+		Total:         total,
+		CurrentVolume: currentVolume,
+	})
+	if err != nil {
+		logger.Warn("Failed updating recovery operation progress", log.Ctx{"err": err})
+	}
+}
+
+// internalRecoverReconstructBackupConfig asks a storage driver that implements storageDrivers.RecoveryProvider
+// to rebuild a volume's backup.Config from its own native metadata (e.g. rbd image metadata, zfs user
+// properties, LV tags), for volumes that predate the backup.yaml feature or whose backup file was lost.
+// Returns storageDrivers.ErrNotImplemented if the driver doesn't support reconstruction.
+func internalRecoverReconstructBackupConfig(pool storagePools.Pool, volName string) (*backup.Config, error) {
+	recoveryProvider, ok := pool.Driver().(storageDrivers.RecoveryProvider)
+	if !ok {
+		return nil, storageDrivers.ErrNotImplemented
+	}
+
+	return recoveryProvider.ReconstructBackupConfig(volName)
+}
+
+// internalRecoverCustomVolumeDirName returns the project name and volume name encoded by a custom volume's
+// on-disk directory name, using the "<project>_<volume>" convention LXD's storage layer uses for every
+// project but default (whose volumes keep their bare name). Splitting on the first "_" is ambiguous for
+// default-project volume names that themselves contain an underscore matching a real project's name (e.g.
+// "db_data" could be project "db", volume "data", or project default, volume "db_data"), so knownProjects is
+// checked first: the directory name is only split as "<project>_<rest>" if everything before the "_" is a
+// known project name, otherwise it's assumed to be a bare default-project volume name.
+func internalRecoverCustomVolumeDirName(dirName string, knownProjects map[string]*db.Project) (string, string) {
+	parts := strings.SplitN(dirName, "_", 2)
+	if len(parts) == 2 && knownProjects[parts[0]] != nil {
+		return parts[0], parts[1]
+	}
+
+	return project.Default, dirName
+}
+
+// internalRecoverScanCustomVolumesOnDisk walks a pool's custom volume directory for volumes that
+// pool.ListUnknownVolumes didn't already report, parsing each one's on-disk backup.yaml into a *backup.Config
+// so it can be discovered and recovered the same way as any other unknown volume. This covers drivers whose
+// pool-level ListUnknownVolumes doesn't enumerate custom volumes itself.
+//
+// This only finds volumes that are plain directories (e.g. on dir or btrfs pools): block-backed custom volumes
+// (zfs zvols, lvm-thin LVs, ceph rbd images) have no on-disk directory to walk and are silently skipped here.
+// internalRecoverScan only finds those if the pool-level ListUnknownVolumes reports them; it does not call the
+// ListUnknownVolumes of storageDrivers.RecoveryProvider that the ceph, lvm and zfs drivers implement.
+func internalRecoverScanCustomVolumesOnDisk(pool storagePools.Pool, known map[string][]*backup.Config, knownProjects map[string]*db.Project) (map[string][]*backup.Config, error) {
+	customDir := shared.VarPath("storage-pools", pool.Name(), "custom")
+
+	entries, err := ioutil.ReadDir(customDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "Failed listing custom volumes directory %q", customDir)
+	}
+
+	found := make(map[string][]*backup.Config)
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.Contains(entry.Name(), shared.SnapshotDelimiter) {
+			continue // Snapshots live in the pool's separate custom-snapshots directory, not as siblings of the volume.
+		}
+
+		projectName, volName := internalRecoverCustomVolumeDirName(entry.Name(), knownProjects)
+
+		alreadyKnown := false
+		for _, poolVol := range known[projectName] {
+			if poolVol.Volume != nil && poolVol.Volume.Name == volName {
+				alreadyKnown = true
+				break
+			}
+		}
+
+		if alreadyKnown {
+			continue
+		}
+
+		poolVol, err := backup.ParseConfigYamlFile(filepath.Join(customDir, entry.Name(), "backup.yaml"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // No backup.yaml and no DB record: nothing we can recover from.
+			}
+
+			return nil, errors.Wrapf(err, "Failed parsing backup.yaml for custom volume %q", volName)
+		}
+
+		found[projectName] = append(found[projectName], poolVol)
+	}
+
+	return found, nil
+}
+
+// internalRecoverDiskDirName encodes a project name and a volume/instance name into the "<project>_<name>"
+// on-disk directory name every project but default uses (see internalRecoverCustomVolumeDirName for the
+// inverse).
+func internalRecoverDiskDirName(projectName string, name string) string {
+	if projectName != project.Default {
+		return projectName + "_" + name
+	}
+
+	return name
+}
+
+// internalRecoverRenameDir renames oldDir to newDir if oldDir exists, registering the reverse rename with
+// revert so that a later failure in the same import leaves neither a half-renamed directory nor a DB record
+// that no longer matches its on-disk name.
+func internalRecoverRenameDir(oldDir string, newDir string, revert *revert.Reverter) error {
+	if !shared.PathExists(oldDir) {
+		return nil
+	}
+
+	err := os.Rename(oldDir, newDir)
+	if err != nil {
+		return errors.Wrapf(err, "Failed renaming %q to %q", oldDir, newDir)
+	}
+
+	revert.Add(func() {
+		os.Rename(newDir, oldDir)
+	})
+
+	return nil
+}
+
+// internalRecoverCustomVolumeDiskDir returns the on-disk directory a custom volume is expected to live in.
+func internalRecoverCustomVolumeDiskDir(poolName string, projectName string, volName string) string {
+	return shared.VarPath("storage-pools", poolName, "custom", internalRecoverDiskDirName(projectName, volName))
+}
+
+// internalRecoverRenameCustomVolumeOnDisk renames a custom volume's on-disk directory (and its snapshots
+// directory, if any) to newName and rewrites its backup.yaml to match, so that the DB record created under
+// the new name (for "rename" conflict resolution) finds its storage where the subsequent
+// pool.ImportCustomVolume call expects it. Both renames are undone via revert if a later step in the same
+// import fails.
+func internalRecoverRenameCustomVolumeOnDisk(pool storagePools.Pool, projectName string, poolVol *backup.Config, newName string, revert *revert.Reverter) error {
+	oldName := poolVol.Volume.Name
+	if oldName == newName {
+		return nil
+	}
+
+	oldDir := internalRecoverCustomVolumeDiskDir(pool.Name(), projectName, oldName)
+	newDir := internalRecoverCustomVolumeDiskDir(pool.Name(), projectName, newName)
+
+	err := internalRecoverRenameDir(oldDir, newDir, revert)
+	if err != nil {
+		return err
+	}
+
+	oldSnapshotsDir := shared.VarPath("storage-pools", pool.Name(), "custom-snapshots", internalRecoverDiskDirName(projectName, oldName))
+	newSnapshotsDir := shared.VarPath("storage-pools", pool.Name(), "custom-snapshots", internalRecoverDiskDirName(projectName, newName))
+
+	err = internalRecoverRenameDir(oldSnapshotsDir, newSnapshotsDir, revert)
+	if err != nil {
+		return err
+	}
+
+	poolVol.Volume.Name = newName
+
+	backupYamlPath := filepath.Join(newDir, "backup.yaml")
+	if shared.PathExists(backupYamlPath) {
+		err = backup.WriteConfigYamlFile(backupYamlPath, poolVol)
+		if err != nil {
+			return errors.Wrapf(err, "Failed rewriting backup.yaml for renamed custom volume %q", newName)
+		}
+	}
+
+	return nil
+}
+
+// internalRecoverInstanceDiskDir returns the on-disk directory an instance of the given type is expected to
+// live in, using the pool's per-type "containers"/"virtual-machines" directory.
+func internalRecoverInstanceDiskDir(poolName string, instanceType string, projectName string, instName string) string {
+	typeDir := "containers"
+	if instanceType == "virtual-machine" {
+		typeDir = "virtual-machines"
+	}
+
+	return shared.VarPath("storage-pools", poolName, typeDir, internalRecoverDiskDirName(projectName, instName))
+}
+
+// internalRecoverRenameInstanceOnDisk renames an instance's on-disk directory (and its snapshots directory,
+// if any) to newName and rewrites its backup.yaml to match, so that the DB record created under the new name
+// (for "rename" conflict resolution) finds its storage where the subsequent pool.ImportInstance call expects
+// it. Both renames are undone via revert if a later step in the same import fails.
+func internalRecoverRenameInstanceOnDisk(pool storagePools.Pool, projectName string, poolVol *backup.Config, newName string, revert *revert.Reverter) error {
+	oldName := poolVol.Container.Name
+	if oldName == newName {
+		return nil
+	}
+
+	typeDir := "containers"
+	if poolVol.Container.Type == "virtual-machine" {
+		typeDir = "virtual-machines"
+	}
+
+	oldDir := internalRecoverInstanceDiskDir(pool.Name(), poolVol.Container.Type, projectName, oldName)
+	newDir := internalRecoverInstanceDiskDir(pool.Name(), poolVol.Container.Type, projectName, newName)
+
+	err := internalRecoverRenameDir(oldDir, newDir, revert)
+	if err != nil {
+		return err
+	}
+
+	oldSnapshotsDir := shared.VarPath("storage-pools", pool.Name(), typeDir+"-snapshots", internalRecoverDiskDirName(projectName, oldName))
+	newSnapshotsDir := shared.VarPath("storage-pools", pool.Name(), typeDir+"-snapshots", internalRecoverDiskDirName(projectName, newName))
+
+	err = internalRecoverRenameDir(oldSnapshotsDir, newSnapshotsDir, revert)
+	if err != nil {
+		return err
+	}
+
+	poolVol.Container.Name = newName
+
+	backupYamlPath := filepath.Join(newDir, "backup.yaml")
+	if shared.PathExists(backupYamlPath) {
+		err = backup.WriteConfigYamlFile(backupYamlPath, poolVol)
+		if err != nil {
+			return errors.Wrapf(err, "Failed rewriting backup.yaml for renamed instance %q", newName)
+		}
+	}
+
+	return nil
 }
 
 // internalRecoverScan provides the discovery and import functionality for both recovery validate and import steps.
-func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOnly bool) response.Response {
+// If op is non-nil, progress metadata is pushed to it before each pool is scanned and each volume is imported, and the scan aborts
+// (unwinding via revert) as soon as the operation is cancelled.
+func internalRecoverScan(d *Daemon, op *operations.Operation, userPools []api.StoragePoolsPost, validateOnly bool, selection []internalRecoverVolumeSelection, conflictPolicy string, renameMap map[string]string) (*internalRecoverValidateResult, error) {
 	var err error
 	var projects map[string]*db.Project
 	var projectProfiles map[string][]*api.Profile
@@ -115,7 +458,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 		return nil
 	})
 	if err != nil {
-		return response.SmartError(errors.Wrapf(err, "Failed getting validate dependency check info"))
+		return nil, errors.Wrapf(err, "Failed getting validate dependency check info")
 	}
 
 	res := internalRecoverValidateResult{}
@@ -123,23 +466,51 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 	revert := revert.New()
 	defer revert.Fail()
 
-	// addDependencyError adds an error to the list of dependency errors if not already present in list.
-	addDependencyError := func(err error) {
+	// blockingDependencyError is set when a volume that's part of this import's selection hits a dependency
+	// problem ConflictPolicy can't resolve per-volume (missing project/profile/network, or a pool config
+	// mismatch), so the whole import must be aborted rather than attempted. Name conflicts are excluded: those
+	// are resolved per-volume by internalRecoverResolveConflict during the import loop below.
+	blockingDependencyError := false
+
+	// addDependencyError adds an error to the deprecated flat list of dependency errors if not already present,
+	// and records it against a volume's own diagnostics so that callers can map it back to its volume. blocking
+	// additionally aborts the whole import unless the volume falls outside this import's selection.
+	addDependencyError := func(diag *internalRecoverValidateVolume, err error, blocking bool) {
 		errStr := err.Error()
 
 		if !shared.StringInSlice(errStr, res.DependencyErrors) {
 			res.DependencyErrors = append(res.DependencyErrors, errStr)
 		}
+
+		if diag != nil {
+			diag.Importable = false
+
+			if blocking && internalRecoverIsSelected(selection, diag.Pool, diag.Project, diag.Name, diag.Type) {
+				blockingDependencyError = true
+			}
+		} else if blocking {
+			blockingDependencyError = true
+		}
 	}
 
 	// Used to store the unknown volumes for each pool & project.
 	poolsProjectVols := make(map[string]map[string][]*backup.Config)
 
+	// Used to store structured diagnostics for each discovered volume, keyed by its backup config pointer so
+	// they can be reused unchanged when building the UnknownVolumes response below.
+	volDiagnostics := make(map[*backup.Config]*internalRecoverValidateVolume)
+
 	// Used to store a handle to each pool containing user supplied config.
 	pools := make(map[string]storagePools.Pool)
 
 	// Iterate the pools finding unknown volumes and perform validation.
-	for _, p := range userPools {
+	for poolIndex, p := range userPools {
+		if op != nil && op.Status() == api.Cancelling {
+			return nil, fmt.Errorf("Recovery scan cancelled")
+		}
+
+		internalRecoverUpdateProgress(op, "scan", poolIndex, len(userPools), p.Name)
+
 		pool, err := storagePools.GetPoolByName(d.State(), p.Name)
 		if err != nil {
 			if errors.Cause(err) == db.ErrNoSuchObject {
@@ -153,15 +524,15 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 
 				pool, err = storagePools.NewTemporary(d.State(), &poolInfo)
 				if err != nil {
-					return response.SmartError(errors.Wrapf(err, "Failed to initialise unknown pool %q", p.Name))
+					return nil, errors.Wrapf(err, "Failed to initialise unknown pool %q", p.Name)
 				}
 
 				err = pool.Driver().Validate(poolInfo.Config)
 				if err != nil {
-					return response.SmartError(errors.Wrapf(err, "Failed config validation for unknown pool %q", p.Name))
+					return nil, errors.Wrapf(err, "Failed config validation for unknown pool %q", p.Name)
 				}
 			} else {
-				return response.SmartError(errors.Wrapf(err, "Failed loading existing pool %q", p.Name))
+				return nil, errors.Wrapf(err, "Failed loading existing pool %q", p.Name)
 			}
 		}
 
@@ -171,7 +542,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 		// Try to mount the pool.
 		ourMount, err := pool.Mount()
 		if err != nil {
-			return response.SmartError(errors.Wrapf(err, "Failed mounting pool %q", pool.Name()))
+			return nil, errors.Wrapf(err, "Failed mounting pool %q", pool.Name())
 		}
 
 		// Unmount pool when done if not existing in DB after function has finished.
@@ -197,10 +568,51 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 			if errors.Cause(err) == storageDrivers.ErrNotImplemented {
 				logger.Error("Pool driver hasn't implemented recovery yet, skipping", log.Ctx{"pool": pool.Name(), "err": err})
 			} else {
-				return response.SmartError(errors.Wrapf(err, "Failed validating volumes on pool %q", pool.Name()))
+				return nil, errors.Wrapf(err, "Failed validating volumes on pool %q", pool.Name())
 			}
 		}
 
+		// Give drivers that implement storageDrivers.RecoveryProvider a chance to reconstruct a missing
+		// backup.Config for volumes discovered on disk whose backup.yaml is absent or unreadable (e.g. the
+		// volume predates the backup-file feature, or the file was lost). A volume that ListUnknownVolumes
+		// could only identify by name comes back with an empty Volume.Config.
+		for _, poolVols := range poolProjectVols {
+			for _, poolVol := range poolVols {
+				if poolVol.Container != nil || poolVol.Volume == nil || len(poolVol.Volume.Config) > 0 {
+					continue // Already has usable metadata.
+				}
+
+				reconstructed, rErr := internalRecoverReconstructBackupConfig(pool, poolVol.Volume.Name)
+				if rErr != nil {
+					if errors.Cause(rErr) != storageDrivers.ErrNotImplemented {
+						logger.Warn("Failed reconstructing backup config for volume", log.Ctx{"pool": pool.Name(), "volume": poolVol.Volume.Name, "err": rErr})
+					}
+
+					continue
+				}
+
+				// Copy the reconstructed config into the existing pointer so it keeps its identity as a
+				// map key for the diagnostics built further down.
+				*poolVol = *reconstructed
+			}
+		}
+
+		if poolProjectVols == nil {
+			poolProjectVols = make(map[string][]*backup.Config)
+		}
+
+		// Pick up any custom volumes the driver's ListUnknownVolumes doesn't enumerate itself yet, by
+		// reading their on-disk backup.yaml directly. Without this, custom volumes whose driver hasn't
+		// been taught to report them would never reach the import logic below.
+		diskVols, err := internalRecoverScanCustomVolumesOnDisk(pool, poolProjectVols, projects)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed scanning on-disk custom volumes for pool %q", pool.Name())
+		}
+
+		for projectName, vols := range diskVols {
+			poolProjectVols[projectName] = append(poolProjectVols[projectName], vols...)
+		}
+
 		// Store for consumption after validation scan to avoid needing to reprocess.
 		poolsProjectVols[p.Name] = poolProjectVols
 
@@ -209,18 +621,42 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 			// Check project exists in database.
 			projectInfo := projects[projectName]
 
+			// Seed a diagnostics entry for every volume up front so it can be found and mutated below,
+			// regardless of which dependency (if any) ends up failing.
+			for _, poolVol := range poolVols {
+				diag := &internalRecoverValidateVolume{Project: projectName, Pool: p.Name, Importable: true}
+				if poolVol.Container != nil {
+					diag.Type = poolVol.Container.Type
+					diag.Name = poolVol.Container.Name
+					diag.SnapshotCount = len(poolVol.Snapshots)
+				} else if poolVol.Volume != nil {
+					diag.Type = "custom"
+					diag.Name = poolVol.Volume.Name
+					diag.SnapshotCount = len(poolVol.VolumeSnapshots)
+				}
+
+				volDiagnostics[poolVol] = diag
+			}
+
 			// Look up effective project names for profiles and networks.
 			var profileProjectname string
 			if projectInfo != nil {
 				profileProjectname = project.ProfileProjectFromRecord(projectInfo)
 			} else {
-				addDependencyError(fmt.Errorf("Project %q", projectName))
+				for _, poolVol := range poolVols {
+					diag := volDiagnostics[poolVol]
+					diag.MissingProject = true
+					addDependencyError(diag, fmt.Errorf("Project %q", projectName), true)
+				}
+
 				continue // Skip further validation if project is missing.
 			}
 
 			for _, poolVol := range poolVols {
+				diag := volDiagnostics[poolVol]
+
 				if poolVol.Container == nil {
-					continue // Skip non-instance volumes.
+					continue // Custom volumes have no profile or network dependencies to check.
 				}
 
 				// Check that the instance's profile dependencies are met.
@@ -233,7 +669,8 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 					}
 
 					if !foundProfile {
-						addDependencyError(fmt.Errorf("Profile %q in project %q", poolInstProfileName, projectName))
+						diag.MissingProfiles = append(diag.MissingProfiles, poolInstProfileName)
+						addDependencyError(diag, fmt.Errorf("Profile %q in project %q", poolInstProfileName, projectName), true)
 					}
 				}
 
@@ -256,39 +693,90 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 					}
 
 					if !foundNetwork {
-						addDependencyError(fmt.Errorf("Network %q in project %q", devConfig["network"], projectName))
+						diag.MissingNetworks = append(diag.MissingNetworks, devConfig["network"])
+						addDependencyError(diag, fmt.Errorf("Network %q in project %q", devConfig["network"], projectName), true)
+					}
+				}
+
+				// Check the instance name doesn't already exist in this project.
+				if instance.Exists(d.State(), projectName, poolVol.Container.Name) {
+					diag.InstanceConflicts = append(diag.InstanceConflicts, poolVol.Container.Name)
+					addDependencyError(diag, fmt.Errorf("Instance %q already exists in project %q", poolVol.Container.Name, projectName), false)
+				}
+			}
+		}
+
+		// Check the pool's own config doesn't conflict with an already existing DB record.
+		if pool.ID() != storagePools.PoolIDTemporary {
+			for _, poolVols := range poolProjectVols {
+				for _, poolVol := range poolVols {
+					if poolVol.Pool == nil {
+						continue
+					}
+
+					for k, v := range poolVol.Pool.Config {
+						if pool.Driver().Config()[k] != v {
+							diag := volDiagnostics[poolVol]
+							msg := fmt.Sprintf("Pool %q config key %q does not match existing pool (%q != %q)", p.Name, k, v, pool.Driver().Config()[k])
+							diag.PoolConflicts = append(diag.PoolConflicts, msg)
+							addDependencyError(diag, fmt.Errorf("%s", msg), true)
+						}
 					}
 				}
 			}
 		}
 	}
 
-	// If in validation mode or if there are dependency errors, return discovered unknown volumes, along with
-	// any dependency errors.
-	if validateOnly || len(res.DependencyErrors) > 0 {
+	// If in validation mode, or a selected volume hit a dependency problem ConflictPolicy can't resolve
+	// per-volume, return discovered unknown volumes, along with their per-volume diagnostics, a summary, and
+	// (for backwards compatibility) the flat dependency errors. Name conflicts don't block this: they're
+	// resolved per-volume by internalRecoverResolveConflict in the import loop below instead.
+	if validateOnly || blockingDependencyError {
+		res.Summary.BytesByPool = make(map[string]int64)
+
 		for poolName, poolProjectVols := range poolsProjectVols {
-			for projectName, poolVols := range poolProjectVols {
+			for _, poolVols := range poolProjectVols {
 				for _, poolVol := range poolVols {
-					if poolVol.Container == nil {
-						continue // Skip non-instance volumes.
+					diag := volDiagnostics[poolVol]
+					if diag == nil {
+						continue // Shouldn't happen, but be defensive.
 					}
 
-					res.UnknownVolumes = append(res.UnknownVolumes, internalRecoverValidateVolume{
-						Type:          poolVol.Container.Type,
-						Name:          poolVol.Container.Name,
-						Pool:          poolName,
-						Project:       projectName,
-						SnapshotCount: len(poolVol.Snapshots),
-					})
+					res.UnknownVolumes = append(res.UnknownVolumes, *diag)
+
+					if poolVol.Container != nil {
+						res.Summary.Instances++
+						res.Summary.Snapshots += len(poolVol.Snapshots)
+					} else if poolVol.Volume != nil {
+						res.Summary.CustomVolumes++
+						res.Summary.Snapshots += len(poolVol.VolumeSnapshots)
+					}
+
+					if poolVol.Volume != nil {
+						size, err := shared.ParseByteSizeString(poolVol.Volume.Config["size"])
+						if err == nil {
+							res.Summary.BytesByPool[poolName] += size
+						}
+					}
 				}
 			}
 		}
 
-		return response.SyncResponse(true, &res)
+		return &res, nil
 	}
 
 	// If in import mode and no dependency errors, then re-create missing DB records.
 
+	// Count the total number of volumes to import up front so progress can report a meaningful total.
+	importTotal := 0
+	for _, poolProjectVols := range poolsProjectVols {
+		for _, poolVols := range poolProjectVols {
+			importTotal += len(poolVols)
+		}
+	}
+
+	importCurrent := 0
+
 	// Create any missing instance and storage volume records.
 	for _, pool := range pools {
 		for projectName, poolVols := range poolsProjectVols[pool.Name()] {
@@ -296,14 +784,61 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 
 			if projectInfo == nil {
 				// Shouldn't happen as we validated this above, but be sure for safety.
-				return response.SmartError(fmt.Errorf("Project %q not found", projectName))
+				return nil, fmt.Errorf("Project %q not found", projectName)
 			}
 
 			profileProjectname := project.ProfileProjectFromRecord(projectInfo)
 
 			for _, poolVol := range poolVols {
+				if op != nil && op.Status() == api.Cancelling {
+					return nil, fmt.Errorf("Recovery import cancelled")
+				}
+
+				importCurrent++
+
 				if poolVol.Container == nil {
-					continue // Skip non-instance volumes.
+					if poolVol.Volume == nil {
+						continue // Nothing usable found for this volume.
+					}
+
+					internalRecoverUpdateProgress(op, "import", importCurrent-1, importTotal, poolVol.Volume.Name)
+
+					if !internalRecoverIsSelected(selection, pool.Name(), projectName, poolVol.Volume.Name, "custom") {
+						continue // Not part of this import's selection.
+					}
+
+					exists, err := internalRecoverCustomVolumeExists(d.State(), pool, projectName, poolVol.Volume.Name)
+					if err != nil {
+						return nil, errors.Wrapf(err, "Failed checking for existing custom volume %q in project %q", poolVol.Volume.Name, projectName)
+					}
+
+					newName, skip, err := internalRecoverResolveConflict(conflictPolicy, renameMap, poolVol.Volume.Name, exists)
+					if err != nil {
+						return nil, errors.Wrapf(err, "Failed resolving conflict for custom volume %q in project %q", poolVol.Volume.Name, projectName)
+					}
+
+					if skip {
+						logger.Warn("Skipping custom volume import due to name conflict", log.Ctx{"project": projectName, "pool": pool.Name(), "volume": poolVol.Volume.Name})
+						continue
+					}
+
+					err = internalRecoverRenameCustomVolumeOnDisk(pool, projectName, poolVol, newName, revert)
+					if err != nil {
+						return nil, errors.Wrapf(err, "Failed renaming custom volume %q to %q in project %q", poolVol.Volume.Name, newName, projectName)
+					}
+
+					err = internalRecoverImportCustomVolume(d.State(), pool, projectName, poolVol, revert)
+					if err != nil {
+						return nil, errors.Wrapf(err, "Failed importing custom volume %q in project %q", poolVol.Volume.Name, projectName)
+					}
+
+					continue
+				}
+
+				internalRecoverUpdateProgress(op, "import", importCurrent-1, importTotal, poolVol.Container.Name)
+
+				if !internalRecoverIsSelected(selection, pool.Name(), projectName, poolVol.Container.Name, poolVol.Container.Type) {
+					continue // Not part of this import's selection.
 				}
 
 				// Create missing storage pool DB record if neeed.
@@ -313,7 +848,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 						logger.Info("Creating storage pool DB record from instance config", log.Ctx{"name": poolVol.Pool.Name, "description": poolVol.Pool.Description, "driver": poolVol.Pool.Driver, "config": poolVol.Pool.Config})
 						_, err = dbStoragePoolCreateAndUpdateCache(d.State(), poolVol.Pool.Name, poolVol.Pool.Description, poolVol.Pool.Driver, poolVol.Pool.Config)
 						if err != nil {
-							return response.SmartError(errors.Wrapf(err, "Failed creating storage pool %q database entry", pool.Name()))
+							return nil, errors.Wrapf(err, "Failed creating storage pool %q database entry", pool.Name())
 						}
 					} else {
 						// Create storage pool DB record from config supplied by user.
@@ -322,7 +857,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 						logger.Info("Creating storage pool DB record from user config", log.Ctx{"name": pool.Name(), "driver": poolDriverName, "config": poolDriverConfig})
 						_, err = dbStoragePoolCreateAndUpdateCache(d.State(), pool.Name(), "", poolDriverName, poolDriverConfig)
 						if err != nil {
-							return response.SmartError(errors.Wrapf(err, "Failed creating storage pool %q database entry", pool.Name()))
+							return nil, errors.Wrapf(err, "Failed creating storage pool %q database entry", pool.Name())
 						}
 					}
 
@@ -334,7 +869,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 
 					newPool, err := storagePools.GetPoolByName(d.State(), pool.Name())
 					if err != nil {
-						return response.SmartError(errors.Wrapf(err, "Failed loading created storage pool %q", pool.Name()))
+						return nil, errors.Wrapf(err, "Failed loading created storage pool %q", pool.Name())
 					}
 
 					// Record this newly created pool so that defer doesn't unmount on return.
@@ -342,6 +877,22 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 					pool = newPool // Replace temporary pool handle with proper one from DB.
 				}
 
+				// Resolve a name conflict with an already existing instance, if any.
+				newName, skip, err := internalRecoverResolveConflict(conflictPolicy, renameMap, poolVol.Container.Name, instance.Exists(d.State(), projectName, poolVol.Container.Name))
+				if err != nil {
+					return nil, errors.Wrapf(err, "Failed resolving conflict for instance %q in project %q", poolVol.Container.Name, projectName)
+				}
+
+				if skip {
+					logger.Warn("Skipping instance import due to name conflict", log.Ctx{"project": projectName, "pool": pool.Name(), "instance": poolVol.Container.Name})
+					continue
+				}
+
+				err = internalRecoverRenameInstanceOnDisk(pool, projectName, poolVol, newName, revert)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Failed renaming instance %q to %q in project %q", poolVol.Container.Name, newName, projectName)
+				}
+
 				// Recover instance.
 				profiles := make([]api.Profile, 0, len(poolVol.Container.Profiles))
 				for _, profileName := range poolVol.Container.Profiles {
@@ -354,7 +905,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 
 				inst, err := internalRecoverImportInstance(d.State(), pool, projectName, poolVol, profiles, revert)
 				if err != nil {
-					return response.SmartError(errors.Wrapf(err, "Failed importing instance %q in project %q", poolVol.Container.Name, projectName))
+					return nil, errors.Wrapf(err, "Failed importing instance %q in project %q", poolVol.Container.Name, projectName)
 				}
 
 				// Recover instance snapshots.
@@ -370,14 +921,14 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 
 					err = internalRecoverImportInstanceSnapshot(d.State(), pool, projectName, poolVol, poolInstSnap, profiles, revert)
 					if err != nil {
-						return response.SmartError(errors.Wrapf(err, "Failed importing instance %q snapshot %q in project %q", poolVol.Container.Name, poolInstSnap.Name, projectName))
+						return nil, errors.Wrapf(err, "Failed importing instance %q snapshot %q in project %q", poolVol.Container.Name, poolInstSnap.Name, projectName)
 					}
 				}
 
 				// Recreate instance mount path and symlinks (must come after snapshot recovery).
 				err = pool.ImportInstance(inst, nil)
 				if err != nil {
-					return response.SmartError(errors.Wrap(err, "Failed importing instance"))
+					return nil, errors.Wrap(err, "Failed importing instance")
 				}
 
 				// Reinitialise the instance's root disk quota even if no size specified (allows the storage driver the
@@ -386,7 +937,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 				if err == nil {
 					err = pool.SetInstanceQuota(inst, rootConfig["size"], rootConfig["size.state"], nil)
 					if err != nil {
-						return response.SmartError(errors.Wrapf(err, "Failed reinitializing root disk quota %q", rootConfig["size"]))
+						return nil, errors.Wrapf(err, "Failed reinitializing root disk quota %q", rootConfig["size"])
 					}
 				}
 			}
@@ -394,7 +945,7 @@ func internalRecoverScan(d *Daemon, userPools []api.StoragePoolsPost, validateOn
 	}
 
 	revert.Success()
-	return response.EmptySyncResponse
+	return nil, nil
 }
 
 // internalRecoverImportInstance recreates the database records for an instance and returns the new instance.
@@ -501,7 +1052,224 @@ func internalRecoverImportInstanceSnapshot(s *state.State, pool storagePools.Poo
 	return nil
 }
 
-// internalRecoverValidate validates the requested pools to be recovered.
+// internalRecoverCustomVolumeExists returns whether a custom volume with the given name already has a DB
+// record on the supplied pool and project.
+func internalRecoverCustomVolumeExists(s *state.State, pool storagePools.Pool, projectName string, name string) (bool, error) {
+	_, err := s.Cluster.GetStoragePoolNodeVolumeID(projectName, name, db.StoragePoolVolumeTypeCustom, pool.ID())
+	if err != nil {
+		if errors.Cause(err) == db.ErrNoSuchObject {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// internalRecoverImportCustomVolume recreates the database records for a custom volume and its snapshots, and
+// re-registers it with the storage pool.
+func internalRecoverImportCustomVolume(s *state.State, pool storagePools.Pool, projectName string, poolVol *backup.Config, revert *revert.Reverter) error {
+	if poolVol.Volume == nil {
+		return fmt.Errorf("Pool volume is not a custom volume")
+	}
+
+	_, err := storagePools.VolumeDBCreate(s, pool.Name(), projectName, poolVol.Volume.Name, poolVol.Volume.Description,
+		db.StoragePoolVolumeTypeNameCustom, false, poolVol.Volume.Config, time.Time{}, time.Time{},
+		poolVol.Volume.ContentType, true, true)
+	if err != nil {
+		return errors.Wrapf(err, "Failed creating custom volume record %q", poolVol.Volume.Name)
+	}
+
+	revert.Add(func() {
+		s.Cluster.StoragePoolVolumeDelete(projectName, poolVol.Volume.Name, db.StoragePoolVolumeTypeCustom, pool.ID())
+	})
+
+	for _, poolVolSnap := range poolVol.VolumeSnapshots {
+		snapName := poolVol.Volume.Name + shared.SnapshotDelimiter + poolVolSnap.Name
+
+		_, err := storagePools.VolumeDBCreate(s, pool.Name(), projectName, snapName, poolVolSnap.Description,
+			db.StoragePoolVolumeTypeNameCustom, true, poolVolSnap.Config, time.Time{}, time.Time{},
+			poolVolSnap.ContentType, true, true)
+		if err != nil {
+			return errors.Wrapf(err, "Failed creating custom volume snapshot record %q", snapName)
+		}
+	}
+
+	// Recreate the volume's mount path and symlinks now that its DB records exist.
+	err = pool.ImportCustomVolume(projectName, poolVol, nil)
+	if err != nil {
+		return errors.Wrap(err, "Failed importing custom volume")
+	}
+
+	return nil
+}
+
+// internalRecoverClusterMembers returns the list of all cluster members.
+func internalRecoverClusterMembers(d *Daemon) ([]db.NodeInfo, error) {
+	var members []db.NodeInfo
+
+	err := d.State().Cluster.Transaction(func(tx *db.ClusterTx) error {
+		nodes, err := tx.GetNodes()
+		if err != nil {
+			return err
+		}
+
+		members = nodes
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed getting cluster member list")
+	}
+
+	return members, nil
+}
+
+// internalRecoverForwardToMember forwards the original validate/import request body to the named path on the
+// given cluster member, waits for the resulting operation to complete, and decodes its validation result (nil
+// if the member's operation didn't report one).
+func internalRecoverForwardToMember(d *Daemon, r *http.Request, member db.NodeInfo, path string, reqBody interface{}) (*internalRecoverValidateResult, error) {
+	client, err := cluster.Connect(member.Address, d.endpoints.NetworkCert(), d.serverCert(), r, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed connecting to cluster member %q", member.Name)
+	}
+
+	resp, _, err := client.RawQuery("POST", path, reqBody, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed requesting recovery on cluster member %q", member.Name)
+	}
+
+	opAPI, err := resp.MetadataAsOperation()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed parsing recovery operation from cluster member %q", member.Name)
+	}
+
+	op, err := client.GetOperationWaitSecs(opAPI.ID, -1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Recovery failed on cluster member %q", member.Name)
+	}
+
+	if op.Err != "" {
+		return nil, fmt.Errorf("Recovery failed on cluster member %q: %s", member.Name, op.Err)
+	}
+
+	if len(op.Metadata) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(op.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var res internalRecoverValidateResult
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed decoding recovery result from cluster member %q", member.Name)
+	}
+
+	return &res, nil
+}
+
+// internalRecoverMergeResult folds a cluster member's validation result into the aggregate being built for
+// the caller, de-duplicating dependency errors.
+func internalRecoverMergeResult(dst *internalRecoverValidateResult, src *internalRecoverValidateResult) {
+	if src == nil {
+		return
+	}
+
+	dst.UnknownVolumes = append(dst.UnknownVolumes, src.UnknownVolumes...)
+	dst.Summary.Instances += src.Summary.Instances
+	dst.Summary.Snapshots += src.Summary.Snapshots
+	dst.Summary.CustomVolumes += src.Summary.CustomVolumes
+
+	if len(src.Summary.BytesByPool) > 0 {
+		if dst.Summary.BytesByPool == nil {
+			dst.Summary.BytesByPool = make(map[string]int64)
+		}
+
+		for poolName, bytes := range src.Summary.BytesByPool {
+			dst.Summary.BytesByPool[poolName] += bytes
+		}
+	}
+
+	for _, errStr := range src.DependencyErrors {
+		if !shared.StringInSlice(errStr, dst.DependencyErrors) {
+			dst.DependencyErrors = append(dst.DependencyErrors, errStr)
+		}
+	}
+}
+
+// internalRecoverRun performs a recovery validate or import, either entirely locally, forwarded to a single
+// cluster member (when the request's "target" query parameter names another member), or fanned out to every
+// cluster member with the results aggregated (when clustered and no target was given). Each member only ever
+// recovers the volumes it can see on its own local storage, so instance and volume DB records end up pinned
+// to the correct node_id without any special-casing here.
+func internalRecoverRun(d *Daemon, r *http.Request, op *operations.Operation, path string, reqBody interface{}, userPools []api.StoragePoolsPost, validateOnly bool, selection []internalRecoverVolumeSelection, conflictPolicy string, renameMap map[string]string) (*internalRecoverValidateResult, error) {
+	target := queryParam(r, "target")
+	localName := d.State().ServerName
+
+	if target != "" && target != localName {
+		members, err := internalRecoverClusterMembers(d)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range members {
+			if member.Name == target {
+				// Keep the target query parameter on the forwarded request so the receiving member
+				// treats this as a single-member recovery rather than electing itself fan-out leader.
+				targetPath := fmt.Sprintf("%s?target=%s", path, url.QueryEscape(target))
+
+				return internalRecoverForwardToMember(d, r, member, targetPath, reqBody)
+			}
+		}
+
+		return nil, fmt.Errorf("Cluster member %q not found", target)
+	}
+
+	// Recover whatever this member can see on its own local storage.
+	res, err := internalRecoverScan(d, op, userPools, validateOnly, selection, conflictPolicy, renameMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		res = &internalRecoverValidateResult{}
+	}
+
+	// A specific (local) member was targeted, or this isn't a cluster: nothing further to fan out to.
+	if target != "" || !d.State().ServerClustered {
+		return res, nil
+	}
+
+	members, err := internalRecoverClusterMembers(d)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		if member.Name == localName {
+			continue // Already recovered above.
+		}
+
+		memberPath := fmt.Sprintf("%s?target=%s", path, url.QueryEscape(member.Name))
+
+		memberRes, err := internalRecoverForwardToMember(d, r, member, memberPath, reqBody)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed recovering cluster member %q", member.Name)
+		}
+
+		internalRecoverMergeResult(res, memberRes)
+	}
+
+	return res, nil
+}
+
+// internalRecoverValidate validates the requested pools to be recovered. It runs as a background operation so
+// that scans of pools with large numbers of instances/snapshots don't time out the HTTP request, and so that
+// lxc monitor and lxc operation show have something to report progress against.
 func internalRecoverValidate(d *Daemon, r *http.Request) response.Response {
 	// Parse the request.
 	req := &internalRecoverValidatePost{}
@@ -510,10 +1278,25 @@ func internalRecoverValidate(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
-	return internalRecoverScan(d, req.Pools, true)
+	run := func(op *operations.Operation) error {
+		res, err := internalRecoverRun(d, r, op, "/internal/recover/validate", req, req.Pools, true, nil, "", nil)
+		if err != nil {
+			return err
+		}
+
+		return op.UpdateMetadata(res)
+	}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassRecoverPool, db.OperationRecoverValidate, nil, nil, run, nil, nil, r)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
 }
 
-// internalRecoverImport performs the pool volume recovery.
+// internalRecoverImport performs the pool volume recovery as a background operation, so that cancelling it
+// unwinds any partially imported records via the same revert.Reverter used by the synchronous code path.
 func internalRecoverImport(d *Daemon, r *http.Request) response.Response {
 	// Parse the request.
 	req := &internalRecoverImportPost{}
@@ -522,5 +1305,270 @@ func internalRecoverImport(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
-	return internalRecoverScan(d, req.Pools, false)
+	run := func(op *operations.Operation) error {
+		res, err := internalRecoverRun(d, r, op, "/internal/recover/import", req, req.Pools, false, req.Selection, req.ConflictPolicy, req.RenameMap)
+		if err != nil {
+			return err
+		}
+
+		// If dependency errors prevented anything being imported, surface them via the operation's
+		// metadata rather than failing the operation outright (matches the previous synchronous
+		// behaviour of returning a 200 with the validation result).
+		if res != nil {
+			return op.UpdateMetadata(res)
+		}
+
+		return nil
+	}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassRecoverImport, db.OperationRecoverImport, nil, nil, run, nil, nil, r)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// internalRecoverImportBackup accepts a backup tarball (as produced by `lxc export`, including the custom
+// volume backup format) and re-injects it directly into the DB and storage pool, without needing the pool's
+// other on-disk volumes to be scanned first. This is the supported recovery path after a total DB loss, before
+// the pool device has necessarily been reattached for a full recover/validate scan. The pool and name recorded
+// in the tarball's backup.yaml can be overridden via the X-LXD-pool and X-LXD-name request headers. The target
+// project is taken from the X-LXD-project request header, or is the default project if that header is absent.
+func internalRecoverImportBackup(d *Daemon, r *http.Request) response.Response {
+	poolOverride := r.Header.Get("X-LXD-pool")
+	projectOverride := r.Header.Get("X-LXD-project")
+	nameOverride := r.Header.Get("X-LXD-name")
+
+	run := func(op *operations.Operation) error {
+		return internalRecoverImportBackupTarball(d, r.Body, poolOverride, projectOverride, nameOverride)
+	}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassRecoverImport, db.OperationRecoverImport, nil, nil, run, nil, nil, r)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// internalRecoverLookupProfiles resolves the named profiles to their api.Profile records in projectName's
+// effective profile project, the same way internalRecoverScan does for the primary recover/import path, so
+// that internalImportRootDevicePopulate has the same profile-derived root device defaults to work from.
+func internalRecoverLookupProfiles(s *state.State, projectName string, profileNames []string) ([]api.Profile, error) {
+	var profiles []api.Profile
+
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		ps, err := tx.GetProjects(db.ProjectFilter{})
+		if err != nil {
+			return err
+		}
+
+		var projectInfo *db.Project
+		for i := range ps {
+			if ps[i].Name == projectName {
+				projectInfo = &ps[i]
+				break
+			}
+		}
+
+		if projectInfo == nil {
+			return fmt.Errorf("Project %q not found", projectName)
+		}
+
+		profileProjectName := project.ProfileProjectFromRecord(projectInfo)
+
+		dbProfiles, err := tx.GetProfiles(db.ProfileFilter{})
+		if err != nil {
+			return err
+		}
+
+		for _, profileName := range profileNames {
+			for _, dbProfile := range dbProfiles {
+				if dbProfile.Project == profileProjectName && dbProfile.Name == profileName {
+					profiles = append(profiles, *db.ProfileToAPI(&dbProfile))
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed looking up profiles for project %q", projectName)
+	}
+
+	return profiles, nil
+}
+
+// internalRecoverStageTarballData moves a backup tarball's unpacked instance or custom volume data (and any
+// snapshots) out of the throwaway unpack directory and into the pool's expected on-disk location, so that
+// pool.ImportInstance/pool.ImportCustomVolume find their storage already in place afterwards. This is what
+// lets /internal/recover/backup recover a pool without the pool device having been reattached and
+// recover/validate-scanned first: the tarball supplies the storage as well as the metadata. Staged
+// directories are removed via revert if a later import step fails, since (unlike recover/import's renames)
+// there's no prior on-disk state to roll back to.
+func internalRecoverStageTarballData(unpackDir string, tarballSubDir string, destDir string, revert *revert.Reverter) error {
+	srcDir := filepath.Join(unpackDir, tarballSubDir)
+	if !shared.PathExists(srcDir) {
+		return nil
+	}
+
+	if shared.PathExists(destDir) {
+		return fmt.Errorf("Storage already exists at %q", destDir)
+	}
+
+	err := os.MkdirAll(filepath.Dir(destDir), 0711)
+	if err != nil {
+		return errors.Wrapf(err, "Failed creating parent directory for %q", destDir)
+	}
+
+	err = os.Rename(srcDir, destDir)
+	if err != nil {
+		return errors.Wrapf(err, "Failed staging %q to %q", srcDir, destDir)
+	}
+
+	revert.Add(func() {
+		os.RemoveAll(destDir)
+	})
+
+	return nil
+}
+
+// internalRecoverImportBackupTarball unpacks a backup tarball, parses its backup.yaml, stages the tarball's
+// instance/volume storage onto the pool's expected on-disk location, and feeds the result into the same
+// instance/custom-volume DB-record creation and pool re-registration used by recover/import, cleaning up on
+// failure via the usual revert.Reverter.
+func internalRecoverImportBackupTarball(d *Daemon, body io.Reader, poolOverride string, projectOverride string, nameOverride string) error {
+	unpackDir, err := ioutil.TempDir(shared.VarPath("unpack"), "lxd_backup_")
+	if err != nil {
+		return errors.Wrap(err, "Failed creating temporary unpack directory")
+	}
+	defer os.RemoveAll(unpackDir)
+
+	tarFile, err := ioutil.TempFile(unpackDir, "backup_")
+	if err != nil {
+		return errors.Wrap(err, "Failed creating temporary tarball file")
+	}
+	defer os.Remove(tarFile.Name())
+
+	_, err = io.Copy(tarFile, body)
+	tarFile.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed writing uploaded backup tarball to disk")
+	}
+
+	err = shared.Unpack(tarFile.Name(), unpackDir, false, false, nil)
+	if err != nil {
+		return errors.Wrap(err, "Failed unpacking backup tarball")
+	}
+
+	poolVol, err := backup.ParseConfigYamlFile(filepath.Join(unpackDir, "backup.yaml"))
+	if err != nil {
+		return errors.Wrap(err, "Failed parsing backup.yaml")
+	}
+
+	projectName := projectOverride
+	if projectName == "" {
+		projectName = project.Default
+	}
+
+	poolName := poolOverride
+	if poolName == "" && poolVol.Pool != nil {
+		poolName = poolVol.Pool.Name
+	}
+
+	if poolName == "" {
+		return fmt.Errorf("Unable to determine target storage pool, and no pool override supplied")
+	}
+
+	if nameOverride != "" {
+		if poolVol.Container != nil {
+			poolVol.Container.Name = nameOverride
+		} else if poolVol.Volume != nil {
+			poolVol.Volume.Name = nameOverride
+		}
+	}
+
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err != nil {
+		return errors.Wrapf(err, "Failed loading storage pool %q", poolName)
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	if poolVol.Container != nil {
+		instDir := internalRecoverInstanceDiskDir(pool.Name(), poolVol.Container.Type, projectName, poolVol.Container.Name)
+
+		err = internalRecoverStageTarballData(unpackDir, poolVol.Container.Type, instDir, revert)
+		if err != nil {
+			return errors.Wrapf(err, "Failed staging storage for instance %q", poolVol.Container.Name)
+		}
+
+		profiles, err := internalRecoverLookupProfiles(d.State(), projectName, poolVol.Container.Profiles)
+		if err != nil {
+			return errors.Wrapf(err, "Failed resolving profiles for instance %q", poolVol.Container.Name)
+		}
+
+		inst, err := internalRecoverImportInstance(d.State(), pool, projectName, poolVol, profiles, revert)
+		if err != nil {
+			return errors.Wrapf(err, "Failed importing instance %q", poolVol.Container.Name)
+		}
+
+		typeDir := "containers"
+		if poolVol.Container.Type == "virtual-machine" {
+			typeDir = "virtual-machines"
+		}
+
+		for _, snap := range poolVol.Snapshots {
+			snapDir := shared.VarPath("storage-pools", pool.Name(), typeDir+"-snapshots", internalRecoverDiskDirName(projectName, poolVol.Container.Name), snap.Name)
+
+			err = internalRecoverStageTarballData(unpackDir, fmt.Sprintf("%s-snapshots/%s", poolVol.Container.Type, snap.Name), snapDir, revert)
+			if err != nil {
+				return errors.Wrapf(err, "Failed staging storage for instance %q snapshot %q", poolVol.Container.Name, snap.Name)
+			}
+
+			snapProfiles, err := internalRecoverLookupProfiles(d.State(), projectName, snap.Profiles)
+			if err != nil {
+				return errors.Wrapf(err, "Failed resolving profiles for instance %q snapshot %q", poolVol.Container.Name, snap.Name)
+			}
+
+			err = internalRecoverImportInstanceSnapshot(d.State(), pool, projectName, poolVol, snap, snapProfiles, revert)
+			if err != nil {
+				return errors.Wrapf(err, "Failed importing instance %q snapshot %q", poolVol.Container.Name, snap.Name)
+			}
+		}
+
+		err = pool.ImportInstance(inst, nil)
+		if err != nil {
+			return errors.Wrap(err, "Failed importing instance")
+		}
+	} else if poolVol.Volume != nil {
+		volDir := internalRecoverCustomVolumeDiskDir(pool.Name(), projectName, poolVol.Volume.Name)
+
+		err = internalRecoverStageTarballData(unpackDir, "volume", volDir, revert)
+		if err != nil {
+			return errors.Wrapf(err, "Failed staging storage for custom volume %q", poolVol.Volume.Name)
+		}
+
+		for _, snap := range poolVol.VolumeSnapshots {
+			snapDir := shared.VarPath("storage-pools", pool.Name(), "custom-snapshots", internalRecoverDiskDirName(projectName, poolVol.Volume.Name), snap.Name)
+
+			err = internalRecoverStageTarballData(unpackDir, fmt.Sprintf("volume-snapshots/%s", snap.Name), snapDir, revert)
+			if err != nil {
+				return errors.Wrapf(err, "Failed staging storage for custom volume %q snapshot %q", poolVol.Volume.Name, snap.Name)
+			}
+		}
+
+		err = internalRecoverImportCustomVolume(d.State(), pool, projectName, poolVol, revert)
+		if err != nil {
+			return errors.Wrapf(err, "Failed importing custom volume %q", poolVol.Volume.Name)
+		}
+	} else {
+		return fmt.Errorf("Backup tarball did not contain a recognised instance or custom volume config")
+	}
+
+	revert.Success()
+
+	return nil
 }
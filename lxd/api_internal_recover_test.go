@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/project"
+)
+
+func TestInternalRecoverIsSelected(t *testing.T) {
+	selection := []internalRecoverVolumeSelection{
+		{Pool: "default", Project: "default", Volume: "c1", Type: "container"},
+	}
+
+	// An empty selection means import everything.
+	if !internalRecoverIsSelected(nil, "default", "default", "anything", "container") {
+		t.Error("empty selection should select everything")
+	}
+
+	if !internalRecoverIsSelected(selection, "default", "default", "c1", "container") {
+		t.Error("matching volume should be selected")
+	}
+
+	if internalRecoverIsSelected(selection, "default", "default", "c2", "container") {
+		t.Error("non-matching volume should not be selected")
+	}
+
+	if internalRecoverIsSelected(selection, "default", "default", "c1", "custom") {
+		t.Error("matching name with different type should not be selected")
+	}
+}
+
+func TestInternalRecoverResolveConflict(t *testing.T) {
+	renameMap := map[string]string{"c1": "c1-recovered"}
+
+	// No existing conflict: always imports under the original name regardless of policy.
+	name, skip, err := internalRecoverResolveConflict(internalRecoverConflictPolicyFail, renameMap, "c1", false)
+	if err != nil || skip || name != "c1" {
+		t.Errorf("got (%q, %v, %v), want (\"c1\", false, nil)", name, skip, err)
+	}
+
+	// Default (empty) policy and "skip" both skip on conflict.
+	for _, policy := range []string{"", internalRecoverConflictPolicySkip} {
+		name, skip, err = internalRecoverResolveConflict(policy, renameMap, "c1", true)
+		if err != nil || !skip {
+			t.Errorf("policy %q: got (%q, %v, %v), want (_, true, nil)", policy, name, skip, err)
+		}
+	}
+
+	// "rename" uses the supplied renameMap entry.
+	name, skip, err = internalRecoverResolveConflict(internalRecoverConflictPolicyRename, renameMap, "c1", true)
+	if err != nil || skip || name != "c1-recovered" {
+		t.Errorf("got (%q, %v, %v), want (\"c1-recovered\", false, nil)", name, skip, err)
+	}
+
+	// "rename" with no renameMap entry is an error.
+	_, _, err = internalRecoverResolveConflict(internalRecoverConflictPolicyRename, renameMap, "c2", true)
+	if err == nil {
+		t.Error("expected error for missing renameMap entry, got nil")
+	}
+
+	// "fail" always errors on conflict.
+	_, _, err = internalRecoverResolveConflict(internalRecoverConflictPolicyFail, renameMap, "c1", true)
+	if err == nil {
+		t.Error("expected error for fail policy on conflict, got nil")
+	}
+
+	// Unrecognised policy is an error.
+	_, _, err = internalRecoverResolveConflict("bogus", renameMap, "c1", true)
+	if err == nil {
+		t.Error("expected error for invalid conflict policy, got nil")
+	}
+}
+
+func TestInternalRecoverCustomVolumeDirName(t *testing.T) {
+	knownProjects := map[string]*db.Project{
+		"foo": {},
+	}
+
+	// A bare name in the default project round-trips unchanged.
+	projectName, volName := internalRecoverCustomVolumeDirName("data", knownProjects)
+	if projectName != project.Default || volName != "data" {
+		t.Errorf("got (%q, %q), want (%q, \"data\")", projectName, volName, project.Default)
+	}
+
+	// A known project prefix is split off.
+	projectName, volName = internalRecoverCustomVolumeDirName("foo_data", knownProjects)
+	if projectName != "foo" || volName != "data" {
+		t.Errorf("got (%q, %q), want (\"foo\", \"data\")", projectName, volName)
+	}
+
+	// An underscore that doesn't match a known project is treated as part of a default-project volume
+	// name, not mistaken for a "<project>_<volume>" split.
+	projectName, volName = internalRecoverCustomVolumeDirName("db_data", knownProjects)
+	if projectName != project.Default || volName != "db_data" {
+		t.Errorf("got (%q, %q), want (%q, \"db_data\")", projectName, volName, project.Default)
+	}
+}
+
+func TestInternalRecoverDiskDirName(t *testing.T) {
+	if got := internalRecoverDiskDirName(project.Default, "data"); got != "data" {
+		t.Errorf("got %q, want \"data\"", got)
+	}
+
+	if got := internalRecoverDiskDirName("foo", "data"); got != "foo_data" {
+		t.Errorf("got %q, want \"foo_data\"", got)
+	}
+}
@@ -0,0 +1,93 @@
+package drivers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// cephLXDMetaPrefix is the prefix used for the custom RBD image metadata keys that mirror a custom volume's
+// backup.yaml config, so that recovery remains possible even if the backup file itself is lost.
+const cephLXDMetaPrefix = "lxd.config."
+
+// ListUnknownVolumes lists the RBD images in the pool's OSD pool that carry "lxd.config.*" image metadata but
+// aren't already in known, reconstructing a backup.Config for each from that metadata. Images are not filtered
+// by volume type: each matching image is reported as a custom volume named after the image.
+func (d *ceph) ListUnknownVolumes(projectName string, known map[string]*backup.Config) ([]*backup.Config, error) {
+	out, err := shared.RunCommand("rbd", "--cluster", d.config["ceph.cluster_name"], "--pool", d.config["ceph.osd.pool_name"], "ls")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed listing RBD images")
+	}
+
+	var volumes []*backup.Config
+	for _, volName := range strings.Split(strings.TrimSpace(out), "\n") {
+		if volName == "" || known[volName] != nil {
+			continue
+		}
+
+		config, err := d.ReconstructBackupConfig(volName)
+		if err != nil {
+			continue // No recoverable lxd.config.* image metadata on this image.
+		}
+
+		volumes = append(volumes, config)
+	}
+
+	return volumes, nil
+}
+
+// ValidateVolume is a no-op: the ceph driver has no extra driver-native consistency checks beyond what the
+// pool-level validation already performs.
+func (d *ceph) ValidateVolume(vol Volume, config *backup.Config) error {
+	return nil
+}
+
+// RepairVolume always returns ErrNotImplemented: there's nothing driver-native left to repair once an image's
+// lxd.config.* metadata has been successfully reconstructed into a backup.Config.
+func (d *ceph) RepairVolume(vol Volume, config *backup.Config) error {
+	return ErrNotImplemented
+}
+
+// ReconstructBackupConfig rebuilds a backup.Config for a custom volume from the "lxd.config.*" image metadata
+// stored on its RBD image, for volumes whose backup.yaml is missing or unreadable.
+func (d *ceph) ReconstructBackupConfig(volName string) (*backup.Config, error) {
+	imageSpec := fmt.Sprintf("%s/%s", d.config["ceph.osd.pool_name"], volName)
+
+	out, err := shared.RunCommand("rbd", "--cluster", d.config["ceph.cluster_name"], "image-meta", "list", imageSpec)
+	if err != nil {
+		return nil, ErrNotImplemented
+	}
+
+	config := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.HasPrefix(line, cephLXDMetaPrefix) {
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimPrefix(line, cephLXDMetaPrefix), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		config[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+	}
+
+	if len(config) == 0 {
+		return nil, ErrNotImplemented
+	}
+
+	return &backup.Config{
+		Volume: &api.StorageVolume{
+			Name: volName,
+			Type: "custom",
+			StorageVolumePut: api.StorageVolumePut{
+				Config: config,
+			},
+		},
+	}, nil
+}
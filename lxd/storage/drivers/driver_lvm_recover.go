@@ -0,0 +1,97 @@
+package drivers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// lvmLXDTagPrefix is the prefix used for the custom LV tags that mirror a custom volume's backup.yaml config,
+// so that recovery remains possible even if the backup file itself is lost.
+const lvmLXDTagPrefix = "lxd.config."
+
+// ListUnknownVolumes lists custom volume LVs in the pool's thin pool that carry "lxd.config.*" tags but
+// aren't already in known, reconstructing a backup.Config for each from those tags.
+func (d *lvm) ListUnknownVolumes(projectName string, known map[string]*backup.Config) ([]*backup.Config, error) {
+	vgName := d.config["lvm.vg_name"]
+
+	out, err := shared.RunCommand("lvs", "--noheadings", "-o", "lv_name", vgName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed listing logical volumes")
+	}
+
+	var volumes []*backup.Config
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		volName := strings.TrimPrefix(strings.TrimSpace(line), "custom_")
+		if volName == "" || volName == strings.TrimSpace(line) || known[volName] != nil {
+			continue // Not a custom volume LV, or already known.
+		}
+
+		config, err := d.ReconstructBackupConfig(volName)
+		if err != nil {
+			continue // No recoverable lxd.config.* tags on this LV.
+		}
+
+		volumes = append(volumes, config)
+	}
+
+	return volumes, nil
+}
+
+// ValidateVolume is a no-op: the lvm driver has no extra driver-native consistency checks beyond what the
+// pool-level validation already performs.
+func (d *lvm) ValidateVolume(vol Volume, config *backup.Config) error {
+	return nil
+}
+
+// RepairVolume always returns ErrNotImplemented: there's nothing driver-native left to repair once an LV's
+// lxd.config.* tags have been successfully reconstructed into a backup.Config.
+func (d *lvm) RepairVolume(vol Volume, config *backup.Config) error {
+	return ErrNotImplemented
+}
+
+// ReconstructBackupConfig rebuilds a backup.Config for a custom volume from the "lxd.config.*" tags stored on
+// its logical volume, for volumes whose backup.yaml is missing or unreadable.
+func (d *lvm) ReconstructBackupConfig(volName string) (*backup.Config, error) {
+	vgName := d.config["lvm.vg_name"]
+	lvSpec := fmt.Sprintf("%s/custom_%s", vgName, volName)
+
+	out, err := shared.RunCommand("lvs", "--noheadings", "-o", "lv_tags", lvSpec)
+	if err != nil {
+		return nil, ErrNotImplemented
+	}
+
+	config := make(map[string]string)
+	for _, tag := range strings.Split(strings.TrimSpace(out), ",") {
+		tag = strings.TrimSpace(tag)
+		if !strings.HasPrefix(tag, lvmLXDTagPrefix) {
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimPrefix(tag, lvmLXDTagPrefix), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		config[fields[0]] = fields[1]
+	}
+
+	if len(config) == 0 {
+		return nil, ErrNotImplemented
+	}
+
+	return &backup.Config{
+		Volume: &api.StorageVolume{
+			Name: volName,
+			Type: "custom",
+			StorageVolumePut: api.StorageVolumePut{
+				Config: config,
+			},
+		},
+	}, nil
+}
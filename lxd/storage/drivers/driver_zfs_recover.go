@@ -0,0 +1,99 @@
+package drivers
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// zfsLXDPropertyPrefix is the prefix used for the custom ZFS dataset properties that mirror a custom volume's
+// backup.yaml config, so that recovery remains possible even if the backup file itself is lost.
+const zfsLXDPropertyPrefix = "lxd:config."
+
+// ListUnknownVolumes lists custom volume datasets under the pool that carry "lxd:config.*" properties but
+// aren't already in known, reconstructing a backup.Config for each from those properties.
+func (d *zfs) ListUnknownVolumes(projectName string, known map[string]*backup.Config) ([]*backup.Config, error) {
+	out, err := shared.RunCommand("zfs", "list", "-H", "-o", "name", "-r", d.dataset(NewVolume(d, d.name, VolumeTypeCustom, ContentTypeFS, "", nil, d.config), false))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed listing custom volume datasets")
+	}
+
+	var volumes []*backup.Config
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+
+		volName := filepath.Base(line)
+		if known[volName] != nil {
+			continue
+		}
+
+		config, err := d.ReconstructBackupConfig(volName)
+		if err != nil {
+			continue // No recoverable lxd:config.* properties on this dataset.
+		}
+
+		volumes = append(volumes, config)
+	}
+
+	return volumes, nil
+}
+
+// ValidateVolume is a no-op: the zfs driver has no extra driver-native consistency checks beyond what the
+// pool-level validation already performs.
+func (d *zfs) ValidateVolume(vol Volume, config *backup.Config) error {
+	return nil
+}
+
+// RepairVolume always returns ErrNotImplemented: there's nothing driver-native left to repair once a
+// dataset's lxd:config.* properties have been successfully reconstructed into a backup.Config.
+func (d *zfs) RepairVolume(vol Volume, config *backup.Config) error {
+	return ErrNotImplemented
+}
+
+// ReconstructBackupConfig rebuilds a backup.Config for a custom volume from the "lxd:config.*" user properties
+// stored locally on its ZFS dataset, for volumes whose backup.yaml is missing or unreadable. Returns
+// ErrNotImplemented if the dataset carries none of these properties (e.g. it was created outside of LXD).
+func (d *zfs) ReconstructBackupConfig(volName string) (*backup.Config, error) {
+	vol := NewVolume(d, d.name, VolumeTypeCustom, ContentTypeFS, volName, nil, d.config)
+	dataset := d.dataset(vol, false)
+
+	out, err := shared.RunCommand("zfs", "get", "-H", "-o", "property,value", "-s", "local", "all", dataset)
+	if err != nil {
+		return nil, ErrNotImplemented
+	}
+
+	config := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], zfsLXDPropertyPrefix) {
+			continue
+		}
+
+		config[strings.TrimPrefix(fields[0], zfsLXDPropertyPrefix)] = fields[1]
+	}
+
+	if len(config) == 0 {
+		return nil, ErrNotImplemented
+	}
+
+	return &backup.Config{
+		Volume: &api.StorageVolume{
+			Name: volName,
+			Type: "custom",
+			StorageVolumePut: api.StorageVolumePut{
+				Config: config,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,85 @@
+package drivers
+
+import (
+	"path/filepath"
+
+	"github.com/lxc/lxd/lxd/backup"
+)
+
+// RecoveryProvider is implemented by storage drivers that can discover and reconstruct custom volumes purely
+// from their own driver-native on-disk or out-of-band metadata (e.g. ZFS user properties, LVM tags, RBD image
+// metadata), for volumes that predate the backup.yaml feature, whose backup file was lost or corrupted, or
+// that ListUnknownVolumes at the pool level doesn't enumerate itself.
+type RecoveryProvider interface {
+	// ListUnknownVolumes returns every custom volume the driver can find natively that isn't already present
+	// in known (keyed by volume name). The ceph, lvm and zfs implementations ignore projectName and return
+	// matching volumes of all projects. Drivers that have no native enumeration beyond what the pool-level
+	// ListUnknownVolumes already does should return nil, nil.
+	ListUnknownVolumes(projectName string, known map[string]*backup.Config) ([]*backup.Config, error)
+
+	// ValidateVolume checks that a volume discovered by ListUnknownVolumes or ReconstructBackupConfig is
+	// actually importable as-is (e.g. its content type matches what the driver can see on disk). Returns a
+	// non-nil error describing the problem if not; RepairVolume can then be tried before giving up.
+	ValidateVolume(vol Volume, config *backup.Config) error
+
+	// RepairVolume attempts to fix a problem ValidateVolume reported (e.g. regenerating a missing dataset
+	// property, fixing up a stale LVM tag) so the volume becomes importable. Returns ErrNotImplemented if the
+	// driver has no repair for the given problem.
+	RepairVolume(vol Volume, config *backup.Config) error
+
+	// ReconstructBackupConfig rebuilds a backup.Config for the named volume from driver-native metadata.
+	// Returns ErrNotImplemented if the volume has no recoverable driver-native metadata.
+	ReconstructBackupConfig(volName string) (*backup.Config, error)
+}
+
+// noRecovery can be embedded by drivers that support none of RecoveryProvider's reconstruction or repair
+// capabilities, so they satisfy the interface without each having to define their own stubs.
+type noRecovery struct{}
+
+// ListUnknownVolumes always returns nil, nil (no driver-native enumeration beyond the pool's own).
+func (noRecovery) ListUnknownVolumes(projectName string, known map[string]*backup.Config) ([]*backup.Config, error) {
+	return nil, nil
+}
+
+// ValidateVolume always returns nil (no driver-native validation beyond the pool's own).
+func (noRecovery) ValidateVolume(vol Volume, config *backup.Config) error {
+	return nil
+}
+
+// RepairVolume always returns ErrNotImplemented.
+func (noRecovery) RepairVolume(vol Volume, config *backup.Config) error {
+	return ErrNotImplemented
+}
+
+// ReconstructBackupConfig always returns ErrNotImplemented.
+func (noRecovery) ReconstructBackupConfig(volName string) (*backup.Config, error) {
+	return nil, ErrNotImplemented
+}
+
+// backupFileRecovery can be embedded by drivers that keep backup.yaml alongside the volume's own data (e.g. on
+// the volume's mounted filesystem), rather than relying purely on out-of-band driver metadata. It implements
+// ReconstructBackupConfig generically by reading that file, given a driver-supplied MountPath callback;
+// ListUnknownVolumes, ValidateVolume and RepairVolume fall back to noRecovery's stubs since finding and
+// fixing up volumes without a backup.yaml still requires driver-native knowledge.
+type backupFileRecovery struct {
+	noRecovery
+
+	// MountPath returns the path backup.yaml would live under for the named volume, without needing the
+	// volume to be mounted first (e.g. a dataset's default mountpoint, or a thin LV's mapped device path).
+	MountPath func(volName string) string
+}
+
+// ReconstructBackupConfig reads backup.yaml from MountPath(volName). Returns ErrNotImplemented if MountPath
+// is unset or the file doesn't exist.
+func (r backupFileRecovery) ReconstructBackupConfig(volName string) (*backup.Config, error) {
+	if r.MountPath == nil {
+		return nil, ErrNotImplemented
+	}
+
+	config, err := backup.ParseConfigYamlFile(filepath.Join(r.MountPath(volName), "backup.yaml"))
+	if err != nil {
+		return nil, ErrNotImplemented
+	}
+
+	return config, nil
+}